@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// diffQuotas compares a single from/to project pair's project-level and region-level
+// quotas and returns the issues found. This is the per-pair unit of work fanned out
+// across the worker pool in compare().
+func diffQuotas(pair projectPair, fromQuotas, toQuotas *Quotas) []Issue {
+	var issues []Issue
+
+	fromProject := pair.fromProject
+	toProject := pair.toProject
+
+	if fromQuotas.project == nil || toQuotas.project == nil {
+		log.Printf("[%s]: skipping project quota comparison with [%s]: one side failed to fetch", fromProject.ProjectId, toProject.ProjectId)
+	} else {
+		diffProjectQuotas(fromProject, toProject, fromQuotas, toQuotas, &issues)
+	}
+
+	if fromQuotas.regionList == nil || toQuotas.regionList == nil {
+		log.Printf("[%s]: skipping region quota comparison with [%s]: one side failed to fetch", fromProject.ProjectId, toProject.ProjectId)
+		return issues
+	}
+
+	diffRegionQuotas(fromProject, toProject, fromQuotas, toQuotas, &issues)
+
+	return issues
+}
+
+// diffProjectQuotas appends any project-level quota mismatches between fromQuotas and
+// toQuotas to issues. Callers must ensure both project fields are non-nil.
+func diffProjectQuotas(fromProject, toProject *Project, fromQuotas, toQuotas *Quotas, issues *[]Issue) {
+	for _, fromProjectQuota := range fromQuotas.project.Quotas {
+		fromProjectQuotaMetric := fromProjectQuota.Metric
+		fromProjectQuotaLimit := fromProjectQuota.Limit
+
+		var toProjectQuota *compute.Quota = nil
+
+		for i := range toQuotas.project.Quotas {
+			if toQuotas.project.Quotas[i].Metric == fromProjectQuotaMetric {
+				toProjectQuota = toQuotas.project.Quotas[i]
+				break
+			}
+		}
+
+		if toProjectQuota == nil {
+			log.Printf("[%s]: Metric %s does not exist", fromProject.ProjectId, fromProjectQuotaMetric)
+			continue
+		}
+
+		toProjectQuotaMetric := toProjectQuota.Metric
+		toProjectQuotaLimit := toProjectQuota.Limit
+
+		if toProjectQuotaLimit != fromProjectQuotaLimit {
+			log.Printf("[%s] [%s] (%f) limit differs from [%s] [%s] (%f)", fromProject.ProjectId, fromProjectQuotaMetric, fromProjectQuotaLimit, toProject.ProjectId, toProjectQuotaMetric, toProjectQuotaLimit)
+			*issues = append(*issues, Issue{
+				fromProjectId: fromProject.DisplayName,
+				toProjectId:   toProject.DisplayName,
+				service:       "compute.googleapis.com",
+				source:        "compute",
+				metric:        fromProjectQuotaMetric,
+				fromLimit:     fromProjectQuotaLimit,
+				toLimit:       toProjectQuotaLimit,
+			})
+		}
+	}
+}
+
+// diffRegionQuotas appends any region-level quota mismatches between fromQuotas and
+// toQuotas to issues. Callers must ensure both regionList fields are non-nil.
+func diffRegionQuotas(fromProject, toProject *Project, fromQuotas, toQuotas *Quotas, issues *[]Issue) {
+	for _, fromRegion := range fromQuotas.regionList.Items {
+		fromRegionName := fromRegion.Name
+		var toRegionFound *compute.Region = nil
+		for _, toRegion := range toQuotas.regionList.Items {
+			if toRegion.Name == fromRegionName {
+				toRegionFound = toRegion
+				break
+			}
+		}
+
+		if toRegionFound == nil {
+			log.Printf("[%s]: Region %s does not exist", fromProject.ProjectId, fromRegionName)
+			continue
+		}
+
+		for _, fromRegionQuota := range fromRegion.Quotas {
+			fromRegionQuotaMetric := fromRegionQuota.Metric
+			fromRegionQuotaLimit := fromRegionQuota.Limit
+
+			var toRegionQuotaFound *compute.Quota = nil
+
+			for i := range toRegionFound.Quotas {
+				if toRegionFound.Quotas[i].Metric == fromRegionQuotaMetric {
+					toRegionQuotaFound = toRegionFound.Quotas[i]
+					break
+				}
+			}
+
+			if toRegionQuotaFound == nil {
+				log.Printf("[%s]/%s: Metric %s does not exist", fromProject.ProjectId, toRegionFound.Name, fromRegionQuotaMetric)
+				continue
+			}
+
+			toRegionQuotaMetric := toRegionQuotaFound.Metric
+			toRegionQuotaLimit := toRegionQuotaFound.Limit
+
+			if toRegionQuotaLimit != fromRegionQuotaLimit {
+				log.Printf("[%s/%s] [%s] (%f) limit differs from [%s/%s] [%s] (%f)", fromProject.ProjectId, fromRegionName, fromRegionQuotaMetric, fromRegionQuotaLimit, toProject.ProjectId, toRegionFound.Name, toRegionQuotaMetric, toRegionQuotaLimit)
+				*issues = append(*issues, Issue{
+					fromProjectId: fromProject.DisplayName,
+					toProjectId:   toProject.DisplayName,
+					region:        fromRegionName,
+					service:       "compute.googleapis.com",
+					source:        "compute",
+					metric:        fromRegionQuotaMetric,
+					fromLimit:     fromRegionQuotaLimit,
+					toLimit:       toRegionQuotaLimit,
+				})
+			}
+		}
+	}
+}
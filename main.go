@@ -5,27 +5,45 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"regexp"
-	"strings"
+	"os"
+	"time"
 
 	"cloud.google.com/go/compute/metadata"
-	"github.com/PuerkitoBio/rehttp"
-	"golang.org/x/oauth2/google"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/option"
 	"gopkg.in/alecthomas/kingpin.v2"
-
-	"google.golang.org/api/cloudresourcemanager/v1"
 )
 
 var (
 	from = kingpin.Flag(
-		"from", "The environment to compare from",
-	).Envar("GCP_QUOTA_COMPARER_FROM").Required().String()
+		"from", "The environment to compare from. Used as the CRM filter query when --from.source=crm.",
+	).Envar("GCP_QUOTA_COMPARER_FROM").String()
 
 	to = kingpin.Flag(
-		"to", "The environment to compare to",
-	).Envar("GCP_QUOTA_COMPARER_TO").Required().String()
+		"to", "The environment to compare to. Used as the CRM filter query when --to.source=crm.",
+	).Envar("GCP_QUOTA_COMPARER_TO").String()
+
+	fromSource = kingpin.Flag(
+		"from.source", "How to discover the source projects: crm, folder, asset-inventory, or file.",
+	).Envar("GCP_QUOTA_COMPARER_FROM_SOURCE").Default("crm").Enum("crm", "folder", "asset-inventory", "file")
+
+	toSource = kingpin.Flag(
+		"to.source", "How to discover the target projects: crm, folder, asset-inventory, or file.",
+	).Envar("GCP_QUOTA_COMPARER_TO_SOURCE").Default("crm").Enum("crm", "folder", "asset-inventory", "file")
+
+	fromScope = kingpin.Flag(
+		"from.scope", "Folder/org resource name (e.g. folders/123, organizations/456) or file path to read source projects from, depending on --from.source.",
+	).Envar("GCP_QUOTA_COMPARER_FROM_SCOPE").String()
+
+	toScope = kingpin.Flag(
+		"to.scope", "Folder/org resource name or file path to read target projects from, depending on --to.source.",
+	).Envar("GCP_QUOTA_COMPARER_TO_SCOPE").String()
+
+	matchBy = kingpin.Flag(
+		"match-by", "How to pair source and target projects: 'regex' (default, uses --regex.from/--regex.to) or 'label:<key>' to pair projects that share a label value.",
+	).Envar("GCP_QUOTA_COMPARER_MATCH_BY").Default("regex").String()
 
 	regexFrom = kingpin.Flag(
 		"regex.from", "The regex to use to match against the source projects.",
@@ -54,6 +72,46 @@ var (
 	gcpRetryStatuses = kingpin.Flag(
 		"gcp.retry-statuses", "The HTTP statuses that should trigger a retry ($GCP_EXPORTER_RETRY_STATUSES)",
 	).Envar("GCP_QUOTA_COMPARER_RETRY_STATUSES").Default("503").Ints()
+
+	oneshot = kingpin.Flag(
+		"oneshot", "Run a single comparison pass and exit, instead of running as a long-lived metrics service.",
+	).Envar("GCP_QUOTA_COMPARER_ONESHOT").Bool()
+
+	listenAddress = kingpin.Flag(
+		"listen-address", "Address to listen on for the metrics HTTP server.",
+	).Envar("GCP_QUOTA_COMPARER_LISTEN_ADDRESS").Default(":9345").String()
+
+	metricsPath = kingpin.Flag(
+		"metrics.path", "Path under which to expose the Prometheus metrics.",
+	).Envar("GCP_QUOTA_COMPARER_METRICS_PATH").Default("/metrics").String()
+
+	scrapeInterval = kingpin.Flag(
+		"scrape-interval", "How often to re-run the project comparison while running as a service.",
+	).Envar("GCP_QUOTA_COMPARER_SCRAPE_INTERVAL").Default("5m").Duration()
+
+	outputFormat = kingpin.Flag(
+		"output.format", "Format to render issues in when running with --oneshot: log, json, csv, or sarif.",
+	).Envar("GCP_QUOTA_COMPARER_OUTPUT_FORMAT").Default("log").Enum("log", "json", "csv", "sarif")
+
+	outputFile = kingpin.Flag(
+		"output", "File to write issues to when running with --oneshot (default: stdout).",
+	).Envar("GCP_QUOTA_COMPARER_OUTPUT").String()
+
+	failOnDiff = kingpin.Flag(
+		"fail-on-diff", "Exit non-zero if any issues are found, for use as a CI drift-detection gate.",
+	).Envar("GCP_QUOTA_COMPARER_FAIL_ON_DIFF").Bool()
+
+	concurrency = kingpin.Flag(
+		"concurrency", "Max number of from/to project pairs to compare concurrently.",
+	).Envar("GCP_QUOTA_COMPARER_CONCURRENCY").Default("10").Int()
+
+	retryReasons = kingpin.Flag(
+		"retry-reasons", "Additional googleapi error reason codes to treat as non-retryable, on top of the built-in set.",
+	).Envar("GCP_QUOTA_COMPARER_RETRY_REASONS").Strings()
+
+	sources = kingpin.Flag(
+		"sources", "Comma-separated quota sources to compare: compute, serviceusage, monitoring.",
+	).Envar("GCP_QUOTA_COMPARER_SOURCES").Default("compute").String()
 )
 
 type Quotas struct {
@@ -66,46 +124,63 @@ type Issue struct {
 	fromProjectId string
 	toProjectId   string
 	region        string
+	service       string
+	source        string
 	metric        string
 	fromLimit     float64
 	toLimit       float64
 }
 
-func GetQuotas(projectId string) (error, *Quotas) {
+func GetQuotas(projectId string, policy RetryPolicy) (error, *Quotas) {
 	// Create context and generate compute.Service
 	ctx := context.Background()
 
-	googleClient, err := google.DefaultClient(ctx, compute.ComputeReadonlyScope)
+	googleClient, err := newRetryingClient(ctx, policy, compute.ComputeReadonlyScope)
 	if err != nil {
-		return fmt.Errorf("Error creating Google client: %v", err), nil
+		return err, nil
 	}
 
-	googleClient.Timeout = *gcpHttpTimeout
-	googleClient.Transport = rehttp.NewTransport(
-		googleClient.Transport, // need to wrap DefaultClient transport
-		rehttp.RetryAll(
-			rehttp.RetryMaxRetries(*gcpMaxRetries),
-			rehttp.RetryStatuses(*gcpRetryStatuses...)), // Cloud support suggests retrying on 503 errors
-		rehttp.ExpJitterDelay(*gcpBackoffJitterBase, *gcpMaxBackoffDuration), // Set timeout to <10s as that is prom default timeout
-	)
-
 	computeService, err := compute.NewService(ctx, option.WithHTTPClient(googleClient))
 
 	if err != nil {
 		log.Fatalf("Failure when getting compute service: %v", err)
 	}
 
-	project, err := computeService.Projects.Get(projectId).Do()
-	if err != nil {
-		log.Printf("Failure when querying project quotas: %v", err)
-		return nil, nil
-	}
+	// Fetch the project and its regions concurrently; both are independent reads
+	// against the same service and dominate GetQuotas' latency.
+	var project *compute.Project
+	var regionList *compute.RegionList
 
-	regionList, err := computeService.Regions.List(projectId).Do()
+	g, _ := errgroup.WithContext(ctx)
 
-	if err != nil {
-		log.Printf("Failure when querying region quotas: %v", err)
-		regionList = nil
+	g.Go(func() error {
+		p, err := computeService.Projects.Get(projectId).Do()
+		if err != nil {
+			if classified := wrapClassifiedError(policy, projectId, err); classified != err {
+				return classified
+			}
+			log.Printf("Failure when querying project quotas: %v", err)
+			return nil
+		}
+		project = p
+		return nil
+	})
+
+	g.Go(func() error {
+		rl, err := computeService.Regions.List(projectId).Do()
+		if err != nil {
+			if classified := wrapClassifiedError(policy, projectId, err); classified != err {
+				return classified
+			}
+			log.Printf("Failure when querying region quotas: %v", err)
+			return nil
+		}
+		regionList = rl
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		return err, nil
 	}
 
 	return nil, &Quotas{
@@ -115,41 +190,6 @@ func GetQuotas(projectId string) (error, *Quotas) {
 	}
 
 }
-func GetProjects(query string) ([]*cloudresourcemanager.Project, error) {
-	// Create context and generate compute.Service
-	ctx := context.Background()
-	cloudresourcemanagerService, err := cloudresourcemanager.NewService(ctx)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// needs to be active because we don't want to query inactive projects
-	filter := strings.Join([]string{"lifecycleState:ACTIVE", query}, " ")
-
-	log.Printf("Project filter: %v", filter)
-
-	projectQuery := cloudresourcemanagerService.Projects.List().Filter(filter)
-
-	response, err := projectQuery.Do()
-	if err != nil {
-		return nil, err
-	}
-
-	projects := response.Projects
-
-	// log.Printf("Retrieved project list: %v", projects)
-
-	return projects, nil
-}
-
-func GetProjectIds(p []*cloudresourcemanager.Project) []string {
-	var list []string
-	for _, project := range p {
-		list = append(list, project.ProjectId)
-	}
-	return list
-}
 
 func GetProjectIdFromMetadata() (string, error) {
 	client := metadata.NewClient(&http.Client{})
@@ -166,135 +206,168 @@ func main() {
 	kingpin.Version("0.1.0")
 	kingpin.Parse()
 
-	fromProjects, err := GetProjects(*from)
+	if *oneshot {
+		runOneshot()
+		return
+	}
 
-	issues := []Issue{}
+	runService()
+}
 
+// runOneshot runs a single comparison pass, renders the result in the requested
+// --output.format, and exits non-zero if --fail-on-diff is set and issues were found.
+func runOneshot() {
+	issues, err := compare()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	toProjects, err := GetProjects(*to)
-	if err != nil {
-		log.Fatal(err)
-	}
+	if *outputFormat == "log" {
+		log.Printf("Found %d issue(s)", len(issues))
+	} else {
+		w, closeFn, err := openOutput(*outputFile)
+		if err != nil {
+			log.Fatalf("Error opening output %q: %v", *outputFile, err)
+		}
+		defer closeFn()
 
-	for _, fromProject := range fromProjects {
-		r := regexp.MustCompile(*regexFrom)
-		projectNameMatch := r.FindStringSubmatch(fromProject.ProjectId)
+		if err := writeIssues(w, *outputFormat, issues); err != nil {
+			log.Fatalf("Error writing %s output: %v", *outputFormat, err)
+		}
+	}
 
-		projectName := projectNameMatch[1]
-		var toProjectFound *cloudresourcemanager.Project
+	if *failOnDiff && len(issues) > 0 {
+		os.Exit(1)
+	}
+}
 
-		r1 := regexp.MustCompile(fmt.Sprintf(*regexTo, projectName))
+// runService starts the HTTP metrics server and periodically re-runs compare(),
+// publishing the results as Prometheus gauges until the process is killed.
+func runService() {
+	http.Handle(*metricsPath, promhttp.Handler())
 
-		err, fromProjectQuotas := GetQuotas(fromProject.ProjectId)
+	go func() {
+		log.Printf("Listening on %s, serving metrics at %s", *listenAddress, *metricsPath)
+		log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	}()
 
+	scrape := func() {
+		start := time.Now()
+		issues, err := compare()
+		scrapeDuration.Observe(time.Since(start).Seconds())
 		if err != nil {
-			log.Fatalf("Error with project %s: %s", fromProject.ProjectId, err)
+			log.Printf("Error during comparison: %v", err)
+			apiErrors.WithLabelValues("compare").Inc()
+			return
 		}
-		for _, toProject := range toProjects {
-			toProjectId := toProject.ProjectId
+		recordIssues(issues)
+		log.Printf("Found %d issue(s)", len(issues))
+	}
 
-			if r1.MatchString(toProjectId) {
-				toProjectFound = toProject
-				break
-			}
-		}
+	scrape()
 
-		if toProjectFound != nil {
-			// log.Printf("Checking %s against %s...", fromProject.ProjectId, toProjectFound.ProjectId)
-			err, toProjectQuotas := GetQuotas(toProjectFound.ProjectId)
-			if err != nil {
-				log.Fatalf("Error with project %s: %s", fromProject.ProjectId, err)
-			}
+	ticker := time.NewTicker(*scrapeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		scrape()
+	}
+}
 
-			for _, fromProjectQuota := range fromProjectQuotas.project.Quotas {
-				fromProjectQuotaMetric := fromProjectQuota.Metric
-				fromProjectQuotaLimit := fromProjectQuota.Limit
+// compare runs a single from/to project comparison pass and returns the quota issues found.
+// Project pairs are fanned out across a bounded pool of goroutines (see --concurrency);
+// quota fetches are deduplicated across pairs via quotaCache since the same project can
+// appear as the "to" side of more than one pair.
+func compare() ([]Issue, error) {
+	resetQuotaCache()
 
-				var toProjectQuota *compute.Quota = nil
+	if *fromSource == "crm" && *from == "" {
+		return nil, fmt.Errorf("--from is required when --from.source=crm")
+	}
+	if *toSource == "crm" && *to == "" {
+		return nil, fmt.Errorf("--to is required when --to.source=crm")
+	}
 
-				for i := range toProjectQuotas.project.Quotas {
-					if toProjectQuotas.project.Quotas[i].Metric == fromProjectQuotaMetric {
-						toProjectQuota = toProjectQuotas.project.Quotas[i]
-						break
-					}
-				}
+	ctx := context.Background()
 
-				if toProjectQuota == nil {
-					log.Printf("[%s]: Metric %s does not exist", fromProject.ProjectId, fromProjectQuotaMetric)
-					continue
-				}
+	fromProjects, err := ResolveProjects(ctx, *fromSource, *from, *fromScope)
+	if err != nil {
+		return nil, err
+	}
 
-				toProjectQuotaMetric := toProjectQuota.Metric
-				toProjectQuotaLimit := toProjectQuota.Limit
-
-				if toProjectQuotaLimit != fromProjectQuotaLimit {
-					log.Printf("[%s] [%s] (%f) limit differs from [%s] [%s] (%f)", fromProject.ProjectId, fromProjectQuotaMetric, fromProjectQuotaLimit, toProjectFound.ProjectId, toProjectQuotaMetric, toProjectQuotaLimit)
-					issues = append(issues, Issue{
-						fromProjectId: fromProject.Name,
-						toProjectId:   toProjectFound.Name,
-						metric:        fromProjectQuotaMetric,
-						fromLimit:     fromProjectQuotaLimit,
-						toLimit:       toProjectQuotaLimit,
-					})
-				}
-			}
+	toProjects, err := ResolveProjects(ctx, *toSource, *to, *toScope)
+	if err != nil {
+		return nil, err
+	}
 
-			// check regions
-			for _, fromRegion := range fromProjectQuotas.regionList.Items {
-				fromRegionName := fromRegion.Name
-				var toRegionFound *compute.Region = nil
-				for _, toRegion := range toProjectQuotas.regionList.Items {
-					toRegionName := toRegion.Name
-					if toRegionName == fromRegionName {
-						toRegionFound = toRegion
-						break
-					}
-				}
+	pairs, err := matchProjectPairs(fromProjects, toProjects)
+	if err != nil {
+		return nil, err
+	}
 
-				if toRegionFound == nil {
-					log.Printf("[%s]: Region %s does not exist", fromProject.ProjectId, fromRegionName)
-					continue
-				}
+	policy := DefaultRetryPolicy().WithExtraReasons(*retryReasons)
 
-				// log.Printf("Checking %s/%s against %s/%s...", fromProject.ProjectId, fromRegionName, toProjectFound.ProjectId, toRegionFound.Name)
+	enabledSources, err := resolveQuotaSources(*sources)
+	if err != nil {
+		return nil, err
+	}
 
-				for _, fromRegionQuota := range fromRegion.Quotas {
-					fromRegionQuotaMetric := fromRegionQuota.Metric
-					fromRegionQuotaLimit := fromRegionQuota.Limit
+	issuesCh := make(chan Issue)
+	var issues []Issue
+	aggregatorDone := make(chan struct{})
 
-					var toRegionQuotaFound *compute.Quota = nil
+	go func() {
+		for issue := range issuesCh {
+			issues = append(issues, issue)
+		}
+		close(aggregatorDone)
+	}()
 
-					for i := range toRegionFound.Quotas {
-						if toRegionFound.Quotas[i].Metric == fromRegionQuotaMetric {
-							toRegionQuotaFound = toRegionFound.Quotas[i]
-							break
-						}
-					}
+	g, ctx := errgroup.WithContext(context.Background())
+	sem := make(chan struct{}, *concurrency)
+
+	for _, pair := range pairs {
+		for _, source := range enabledSources {
+			pair, source := pair, source
 
-					if toRegionQuotaFound == nil {
-						log.Printf("[%s]/%s: Metric %s does not exist", fromProject.ProjectId, toRegionFound.Name, fromRegionQuotaMetric)
-						continue
+			g.Go(func() error {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				defer func() { <-sem }()
+
+				sourceIssues, err := source.Compare(ctx, pair, policy)
+				if err != nil {
+					label := classifyAPIError(err)
+					apiErrors.WithLabelValues(label).Inc()
+					if label == "unknown" {
+						return err
 					}
+					log.Printf("[%s -> %s] [%s]: %v", pair.fromProject.ProjectId, pair.toProject.ProjectId, source.Name(), err)
+					return nil
+				}
 
-					toRegionQuotaMetric := toRegionQuotaFound.Metric
-					toRegionQuotaLimit := toRegionQuotaFound.Limit
-
-					if toRegionQuotaLimit != fromRegionQuotaLimit {
-						log.Printf("[%s/%s] [%s] (%f) limit differs from [%s/%s] [%s] (%f)", fromProject.ProjectId, fromRegionName, fromRegionQuotaMetric, fromRegionQuotaLimit, toProjectFound.ProjectId, toRegionFound.Name, toRegionQuotaMetric, toRegionQuotaLimit)
-						issues = append(issues, Issue{
-							fromProjectId: fromProject.Name,
-							toProjectId:   toProjectFound.Name,
-							region:        fromRegionName,
-							metric:        fromRegionQuotaMetric,
-							fromLimit:     fromRegionQuotaLimit,
-							toLimit:       toRegionQuotaLimit,
-						})
+				for _, issue := range sourceIssues {
+					select {
+					case issuesCh <- issue:
+					case <-ctx.Done():
+						return ctx.Err()
 					}
 				}
-			}
+
+				return nil
+			})
 		}
 	}
+
+	err = g.Wait()
+	close(issuesCh)
+	<-aggregatorDone
+
+	if err != nil {
+		return nil, err
+	}
+
+	return issues, nil
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// projectPair is a matched from/to project that needs its quotas diffed.
+type projectPair struct {
+	fromProject *Project
+	toProject   *Project
+}
+
+// matchProjectPairs pairs from/to projects using the strategy selected by --match-by:
+// "regex" (the default, via --regex.from/--regex.to) or "label:<key>" to pair projects
+// that share a label value instead of relying on brittle name patterns.
+func matchProjectPairs(fromProjects, toProjects []*Project) ([]projectPair, error) {
+	if strings.HasPrefix(*matchBy, "label:") {
+		key := strings.TrimPrefix(*matchBy, "label:")
+		return matchProjectPairsByLabel(fromProjects, toProjects, key), nil
+	}
+
+	if *matchBy != "regex" {
+		return nil, fmt.Errorf("unknown --match-by %q", *matchBy)
+	}
+
+	return matchProjectPairsByRegex(fromProjects, toProjects), nil
+}
+
+// matchProjectPairsByRegex pairs up from/to projects by name using regexFrom/regexTo,
+// the same way the original serial implementation did.
+func matchProjectPairsByRegex(fromProjects, toProjects []*Project) []projectPair {
+	r := regexp.MustCompile(*regexFrom)
+
+	pairs := make([]projectPair, 0, len(fromProjects))
+	for _, fromProject := range fromProjects {
+		projectNameMatch := r.FindStringSubmatch(fromProject.ProjectId)
+		if len(projectNameMatch) < 2 {
+			log.Printf("[%s]: does not match --regex.from %q", fromProject.ProjectId, *regexFrom)
+			continue
+		}
+		projectName := projectNameMatch[1]
+
+		r1 := regexp.MustCompile(fmt.Sprintf(*regexTo, projectName))
+
+		for _, toProject := range toProjects {
+			if r1.MatchString(toProject.ProjectId) {
+				pairs = append(pairs, projectPair{fromProject: fromProject, toProject: toProject})
+				break
+			}
+		}
+	}
+
+	return pairs
+}
+
+// matchProjectPairsByLabel pairs each from-project with the to-project that shares the
+// same value for label key, instead of matching on project name.
+func matchProjectPairsByLabel(fromProjects, toProjects []*Project, key string) []projectPair {
+	toByLabel := make(map[string]*Project, len(toProjects))
+	for _, toProject := range toProjects {
+		if value, ok := toProject.Labels[key]; ok {
+			toByLabel[value] = toProject
+		}
+	}
+
+	pairs := make([]projectPair, 0, len(fromProjects))
+	for _, fromProject := range fromProjects {
+		value, ok := fromProject.Labels[key]
+		if !ok {
+			log.Printf("[%s]: missing label %q", fromProject.ProjectId, key)
+			continue
+		}
+
+		toProject, ok := toByLabel[value]
+		if !ok {
+			log.Printf("[%s]: no project found with label %s=%s", fromProject.ProjectId, key, value)
+			continue
+		}
+
+		pairs = append(pairs, projectPair{fromProject: fromProject, toProject: toProject})
+	}
+
+	return pairs
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestMatchProjectPairsByLabel(t *testing.T) {
+	fromProjects := []*Project{
+		{ProjectId: "from-a", Labels: map[string]string{"env": "prod"}},
+		{ProjectId: "from-b", Labels: map[string]string{"env": "staging"}},
+		{ProjectId: "from-c"},
+	}
+	toProjects := []*Project{
+		{ProjectId: "to-a", Labels: map[string]string{"env": "prod"}},
+	}
+
+	pairs := matchProjectPairsByLabel(fromProjects, toProjects, "env")
+
+	if len(pairs) != 1 {
+		t.Fatalf("expected 1 pair, got %d: %+v", len(pairs), pairs)
+	}
+	if pairs[0].fromProject.ProjectId != "from-a" || pairs[0].toProject.ProjectId != "to-a" {
+		t.Errorf("unexpected pair: %+v", pairs[0])
+	}
+}
+
+func TestMatchProjectPairsByRegex(t *testing.T) {
+	oldFrom, oldTo := *regexFrom, *regexTo
+	defer func() { *regexFrom, *regexTo = oldFrom, oldTo }()
+
+	*regexFrom = `^prj-(\w+)-dev$`
+	*regexTo = `^prj-%s-prod$`
+
+	fromProjects := []*Project{
+		{ProjectId: "prj-widgets-dev"},
+		{ProjectId: "does-not-match"},
+	}
+	toProjects := []*Project{
+		{ProjectId: "prj-widgets-prod"},
+	}
+
+	pairs := matchProjectPairsByRegex(fromProjects, toProjects)
+
+	if len(pairs) != 1 {
+		t.Fatalf("expected 1 pair, got %d: %+v", len(pairs), pairs)
+	}
+	if pairs[0].fromProject.ProjectId != "prj-widgets-dev" || pairs[0].toProject.ProjectId != "prj-widgets-prod" {
+		t.Errorf("unexpected pair: %+v", pairs[0])
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	quotaLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gcp_quota_limit",
+		Help: "The quota limit reported by GCP for a given project/region/metric.",
+	}, []string{"project", "region", "metric"})
+
+	quotaDelta = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gcp_quota_delta",
+		Help: "The difference (to - from) between two compared projects' quota limits for a given region/metric.",
+	}, []string{"from_project", "to_project", "region", "service", "source", "metric"})
+
+	scrapeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "gcp_quota_comparer_scrape_duration_seconds",
+		Help: "Time taken to complete a full from/to project comparison pass.",
+	})
+
+	apiErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gcp_quota_comparer_api_errors_total",
+		Help: "Count of errors returned by the GCP APIs, labelled by error class (api_disabled, permission_denied, quota_exceeded, unknown), or \"compare\" when a whole comparison pass fails.",
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(quotaLimit, quotaDelta, scrapeDuration, apiErrors)
+}
+
+// recordIssues resets and repopulates gcp_quota_delta from a fresh comparison pass.
+// gcp_quota_limit is set separately by recordQuotas and is not reset here.
+func recordIssues(issues []Issue) {
+	quotaDelta.Reset()
+
+	for _, issue := range issues {
+		quotaDelta.WithLabelValues(issue.fromProjectId, issue.toProjectId, issue.region, issue.service, issue.source, issue.metric).Set(issue.toLimit - issue.fromLimit)
+	}
+}
+
+// recordQuotas publishes the raw quota limits seen for a single project so gcp_quota_limit
+// reflects current state even for metrics that don't differ between projects.
+func recordQuotas(projectId string, q *Quotas) {
+	if q == nil {
+		return
+	}
+
+	if q.project != nil {
+		for _, quota := range q.project.Quotas {
+			quotaLimit.WithLabelValues(projectId, "", quota.Metric).Set(quota.Limit)
+		}
+	}
+
+	if q.regionList != nil {
+		for _, region := range q.regionList.Items {
+			for _, quota := range region.Quotas {
+				quotaLimit.WithLabelValues(projectId, region.Name, quota.Metric).Set(quota.Limit)
+			}
+		}
+	}
+}
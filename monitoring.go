@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+// quotaLimitMetricType is the Cloud Monitoring metric published by every GCP service
+// for its Service Usage quotas, alongside the matching .../quota/usage metric.
+const quotaLimitMetricType = "serviceruntime.googleapis.com/quota/limit"
+
+// quotaMetricKey identifies a single quota limit time series by the service that owns
+// it and the quota metric name, mirroring the resource/metric labels Cloud Monitoring
+// attaches to serviceruntime.googleapis.com/quota/* points.
+type quotaMetricKey struct {
+	service string
+	metric  string
+}
+
+// monitoringQuotaSource compares the most recent serviceruntime.googleapis.com/quota/limit
+// value for every quota metric Cloud Monitoring has time series for.
+type monitoringQuotaSource struct{}
+
+func (monitoringQuotaSource) Name() string { return "monitoring" }
+
+func (monitoringQuotaSource) Compare(ctx context.Context, pair projectPair, policy RetryPolicy) ([]Issue, error) {
+	fromLimits, err := quotaLimitTimeSeries(ctx, policy, pair.fromProject.ProjectId)
+	if err != nil {
+		return nil, fmt.Errorf("error with project %s: %v", pair.fromProject.ProjectId, err)
+	}
+
+	toLimits, err := quotaLimitTimeSeries(ctx, policy, pair.toProject.ProjectId)
+	if err != nil {
+		return nil, fmt.Errorf("error with project %s: %v", pair.toProject.ProjectId, err)
+	}
+
+	var issues []Issue
+
+	for key, fromLimit := range fromLimits {
+		toLimit, ok := toLimits[key]
+		if !ok {
+			log.Printf("[%s]: Monitoring metric %s/%s does not exist", pair.fromProject.ProjectId, key.service, key.metric)
+			continue
+		}
+
+		if toLimit != fromLimit {
+			issues = append(issues, Issue{
+				fromProjectId: pair.fromProject.DisplayName,
+				toProjectId:   pair.toProject.DisplayName,
+				service:       key.service,
+				source:        "monitoring",
+				metric:        key.metric,
+				fromLimit:     fromLimit,
+				toLimit:       toLimit,
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// quotaLimitTimeSeries returns the latest quota/limit point for every service/metric
+// pair Cloud Monitoring has data for on projectId, over the last 10 minutes.
+func quotaLimitTimeSeries(ctx context.Context, policy RetryPolicy, projectId string) (map[quotaMetricKey]float64, error) {
+	client, err := newRetryingClient(ctx, policy, monitoring.MonitoringReadScope)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := monitoring.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	call := service.Projects.TimeSeries.List(fmt.Sprintf("projects/%s", projectId)).
+		Filter(fmt.Sprintf(`metric.type="%s"`, quotaLimitMetricType)).
+		IntervalStartTime(now.Add(-10 * time.Minute).Format(time.RFC3339)).
+		IntervalEndTime(now.Format(time.RFC3339))
+
+	limits := map[quotaMetricKey]float64{}
+
+	err = call.Pages(ctx, func(page *monitoring.ListTimeSeriesResponse) error {
+		for _, series := range page.TimeSeries {
+			if len(series.Points) == 0 {
+				continue
+			}
+
+			// quota/limit is a gauge of type INT64; DOUBLE points are accepted too and
+			// any other value type is skipped.
+			var value float64
+			switch point := series.Points[0].Value; {
+			case point.Int64Value != nil:
+				value = float64(*point.Int64Value)
+			case point.DoubleValue != nil:
+				value = *point.DoubleValue
+			default:
+				continue
+			}
+
+			key := quotaMetricKey{
+				service: series.Resource.Labels["service"],
+				metric:  series.Metric.Labels["quota_metric"],
+			}
+			limits[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
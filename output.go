@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// issueRecord is the exported, serializable view of an Issue. Issue itself keeps
+// unexported fields, so encoding/json needs a DTO to marshal.
+type issueRecord struct {
+	FromProject string  `json:"fromProject"`
+	ToProject   string  `json:"toProject"`
+	Region      string  `json:"region,omitempty"`
+	Service     string  `json:"service,omitempty"`
+	Source      string  `json:"source,omitempty"`
+	Metric      string  `json:"metric"`
+	FromLimit   float64 `json:"fromLimit"`
+	ToLimit     float64 `json:"toLimit"`
+}
+
+func toIssueRecords(issues []Issue) []issueRecord {
+	records := make([]issueRecord, 0, len(issues))
+	for _, issue := range issues {
+		records = append(records, issueRecord{
+			FromProject: issue.fromProjectId,
+			ToProject:   issue.toProjectId,
+			Region:      issue.region,
+			Service:     issue.service,
+			Source:      issue.source,
+			Metric:      issue.metric,
+			FromLimit:   issue.fromLimit,
+			ToLimit:     issue.toLimit,
+		})
+	}
+	return records
+}
+
+// writeIssues renders issues to w in the requested format. "log" is handled by the
+// caller via log.Printf and should never reach here.
+func writeIssues(w io.Writer, format string, issues []Issue) error {
+	switch format {
+	case "json":
+		return writeIssuesJSON(w, issues)
+	case "csv":
+		return writeIssuesCSV(w, issues)
+	case "sarif":
+		return writeIssuesSARIF(w, issues)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+func writeIssuesJSON(w io.Writer, issues []Issue) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toIssueRecords(issues))
+}
+
+func writeIssuesCSV(w io.Writer, issues []Issue) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"from_project", "to_project", "region", "service", "source", "metric", "from_limit", "to_limit"}); err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		err := cw.Write([]string{
+			issue.fromProjectId,
+			issue.toProjectId,
+			issue.region,
+			issue.service,
+			issue.source,
+			issue.metric,
+			strconv.FormatFloat(issue.fromLimit, 'f', -1, 64),
+			strconv.FormatFloat(issue.toLimit, 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// SARIF (Static Analysis Results Interchange Format) 2.1.0, trimmed to the fields
+// consumers (GitHub code scanning, etc.) actually read.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+const sarifRuleIDQuotaDrift = "quota-limit-drift"
+
+func writeIssuesSARIF(w io.Writer, issues []Issue) error {
+	results := make([]sarifResult, 0, len(issues))
+
+	for _, issue := range issues {
+		location := issue.fromProjectId
+		if issue.region != "" {
+			location = fmt.Sprintf("%s/%s", issue.fromProjectId, issue.region)
+		}
+
+		results = append(results, sarifResult{
+			RuleID: sarifRuleIDQuotaDrift,
+			Level:  "warning",
+			Message: sarifMessage{
+				Text: fmt.Sprintf("Quota %s differs between %s (%g) and %s (%g)", issue.metric, issue.fromProjectId, issue.fromLimit, issue.toProjectId, issue.toLimit),
+			},
+			Locations: []sarifLocation{{
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: fmt.Sprintf("%s/%s", location, issue.metric)}},
+			}},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "gcp-quota-comparer",
+				InformationURI: "https://github.com/greg-reese/gcp-quota-comparer",
+				Rules:          []sarifRule{{ID: sarifRuleIDQuotaDrift, Name: "QuotaLimitDrift"}},
+			}},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// openOutput returns the writer for --output, defaulting to stdout, along with a
+// close func the caller should defer.
+func openOutput(path string) (io.Writer, func() error, error) {
+	if path == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, f.Close, nil
+}
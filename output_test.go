@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteIssuesCSV(t *testing.T) {
+	issues := []Issue{
+		{
+			fromProjectId: "proj-a",
+			toProjectId:   "proj-b",
+			region:        "us-central1",
+			service:       "compute.googleapis.com",
+			source:        "compute",
+			metric:        "CPUS",
+			fromLimit:     8,
+			toLimit:       16,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeIssuesCSV(&buf, issues); err != nil {
+		t.Fatalf("writeIssuesCSV returned error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a header row and one data row, got %d lines: %q", len(lines), buf.String())
+	}
+	if lines[0] != "from_project,to_project,region,service,source,metric,from_limit,to_limit" {
+		t.Errorf("unexpected header row: %q", lines[0])
+	}
+	if lines[1] != "proj-a,proj-b,us-central1,compute.googleapis.com,compute,CPUS,8,16" {
+		t.Errorf("unexpected data row: %q", lines[1])
+	}
+}
+
+func TestWriteIssuesSARIF(t *testing.T) {
+	issues := []Issue{
+		{
+			fromProjectId: "proj-a",
+			toProjectId:   "proj-b",
+			metric:        "CPUS",
+			fromLimit:     8,
+			toLimit:       16,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeIssuesSARIF(&buf, issues); err != nil {
+		t.Fatalf("writeIssuesSARIF returned error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{`"$schema"`, `"ruleId": "quota-limit-drift"`, `"fullyQualifiedName": "proj-a/CPUS"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected SARIF output to contain %q, got: %s", want, out)
+		}
+	}
+}
+
+func TestWriteIssuesUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeIssues(&buf, "yaml", nil); err == nil {
+		t.Fatal("expected an error for an unknown output format")
+	}
+}
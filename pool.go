@@ -0,0 +1,33 @@
+package main
+
+import "sync"
+
+// quotaCacheEntry memoizes a single project's GetQuotas result behind a sync.Once so
+// concurrent pairs sharing a project (e.g. the same "to" project matched by several
+// "from" projects) only fetch it once.
+type quotaCacheEntry struct {
+	once   sync.Once
+	err    error
+	quotas *Quotas
+}
+
+var quotaCache sync.Map // projectId -> *quotaCacheEntry
+
+// resetQuotaCache drops all cached quotas so the next compare() pass re-fetches
+// everything instead of reusing results from a previous scrape.
+func resetQuotaCache() {
+	quotaCache = sync.Map{}
+}
+
+// cachedGetQuotas wraps GetQuotas with a per-projectId cache, deduplicating concurrent
+// fetches of the same project across the worker pool in compare().
+func cachedGetQuotas(projectId string, policy RetryPolicy) (error, *Quotas) {
+	actual, _ := quotaCache.LoadOrStore(projectId, &quotaCacheEntry{})
+	entry := actual.(*quotaCacheEntry)
+
+	entry.once.Do(func() {
+		entry.err, entry.quotas = GetQuotas(projectId, policy)
+	})
+
+	return entry.err, entry.quotas
+}
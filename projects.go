@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"google.golang.org/api/cloudasset/v1"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	resourcemanagerv3 "google.golang.org/api/cloudresourcemanager/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// Project is the discovery-source-agnostic view of a GCP project. Every ProjectSource
+// converts whatever it fetches (CRM v1/v3, Cloud Asset Inventory, a static file) into
+// this shape so the rest of the comparer doesn't care where a project came from.
+type Project struct {
+	ProjectId   string
+	DisplayName string
+	Labels      map[string]string
+}
+
+// ResolveProjects discovers the set of projects for one side of a comparison using the
+// mode selected by --from.source/--to.source. query is the CRM filter (crm mode only);
+// scope is the folder/org resource name or file path (folder/asset-inventory/file modes).
+func ResolveProjects(ctx context.Context, source, query, scope string) ([]*Project, error) {
+	switch source {
+	case "crm":
+		return GetProjects(query)
+	case "folder":
+		return listProjectsUnderScope(ctx, scope)
+	case "asset-inventory":
+		return listProjectsViaAssetInventory(ctx, scope)
+	case "file":
+		return listProjectsFromFile(scope)
+	default:
+		return nil, fmt.Errorf("unknown project source %q", source)
+	}
+}
+
+// GetProjects discovers projects via cloudresourcemanager/v1 Projects.List, matching a
+// user-supplied filter. This is the original, default discovery mode.
+func GetProjects(query string) ([]*Project, error) {
+	ctx := context.Background()
+	cloudresourcemanagerService, err := cloudresourcemanager.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// needs to be active because we don't want to query inactive projects
+	filter := strings.Join([]string{"lifecycleState:ACTIVE", query}, " ")
+
+	log.Printf("Project filter: %v", filter)
+
+	response, err := cloudresourcemanagerService.Projects.List().Filter(filter).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	projects := make([]*Project, 0, len(response.Projects))
+	for _, p := range response.Projects {
+		projects = append(projects, &Project{ProjectId: p.ProjectId, DisplayName: p.Name, Labels: p.Labels})
+	}
+
+	return projects, nil
+}
+
+// listProjectsUnderScope recursively enumerates every project under a folder or org
+// (scope is e.g. "folders/123" or "organizations/456") using cloudresourcemanager/v3.
+func listProjectsUnderScope(ctx context.Context, scope string) ([]*Project, error) {
+	service, err := resourcemanagerv3.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return listProjectsRecursive(ctx, service, scope)
+}
+
+func listProjectsRecursive(ctx context.Context, service *resourcemanagerv3.Service, parent string) ([]*Project, error) {
+	var projects []*Project
+
+	err := service.Projects.List().Parent(parent).Pages(ctx, func(page *resourcemanagerv3.ListProjectsResponse) error {
+		for _, p := range page.Projects {
+			projects = append(projects, &Project{ProjectId: p.ProjectId, DisplayName: p.DisplayName, Labels: p.Labels})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.Folders.List().Parent(parent).Pages(ctx, func(page *resourcemanagerv3.ListFoldersResponse) error {
+		for _, folder := range page.Folders {
+			childProjects, err := listProjectsRecursive(ctx, service, folder.Name)
+			if err != nil {
+				return err
+			}
+			projects = append(projects, childProjects...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// listProjectsViaAssetInventory queries Cloud Asset Inventory for
+// cloudresourcemanager.googleapis.com/Project assets under scope (a folder, org, or
+// project resource name), for environments where CRM listing permissions aren't granted
+// but org-wide Asset Inventory read access is.
+func listProjectsViaAssetInventory(ctx context.Context, scope string) ([]*Project, error) {
+	service, err := cloudasset.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []*Project
+
+	err = service.V1.SearchAllResources(scope).
+		AssetTypes("cloudresourcemanager.googleapis.com/Project").
+		Pages(ctx, func(page *cloudasset.SearchAllResourcesResponse) error {
+			for _, result := range page.Results {
+				projects = append(projects, &Project{
+					ProjectId:   projectIdFromAssetName(result.Name),
+					DisplayName: result.DisplayName,
+					Labels:      result.Labels,
+				})
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// projectIdFromAssetName extracts the project ID from an asset resource name, e.g.
+// "//cloudresourcemanager.googleapis.com/projects/my-project" -> "my-project".
+func projectIdFromAssetName(name string) string {
+	if i := strings.LastIndex(name, "/"); i != -1 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// fileProject is the shape expected when reading a static project list from YAML/JSON.
+type fileProject struct {
+	ProjectId   string            `json:"projectId" yaml:"projectId"`
+	DisplayName string            `json:"displayName" yaml:"displayName"`
+	Labels      map[string]string `json:"labels" yaml:"labels"`
+}
+
+// listProjectsFromFile reads a static project list from a YAML or JSON file (selected
+// by extension), for environments where neither CRM nor Asset Inventory access is available.
+func listProjectsFromFile(path string) ([]*Project, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fileProject
+
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		err = yaml.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing project file %s: %v", path, err)
+	}
+
+	projects := make([]*Project, 0, len(entries))
+	for _, e := range entries {
+		projects = append(projects, &Project{ProjectId: e.ProjectId, DisplayName: e.DisplayName, Labels: e.Labels})
+	}
+
+	return projects, nil
+}
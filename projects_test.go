@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestProjectIdFromAssetName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"//cloudresourcemanager.googleapis.com/projects/my-project", "my-project"},
+		{"projects/my-project", "my-project"},
+		{"my-project", "my-project"},
+	}
+
+	for _, tt := range tests {
+		if got := projectIdFromAssetName(tt.name); got != tt.want {
+			t.Errorf("projectIdFromAssetName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
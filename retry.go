@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/PuerkitoBio/rehttp"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+)
+
+// Typed errors surfaced by GetQuotas so callers can distinguish a permanent failure
+// from one that's merely slow and worth retrying later.
+var (
+	ErrAPIDisabled      = errors.New("gcp API is disabled for this project")
+	ErrPermissionDenied = errors.New("gcp denied permission for this request")
+	ErrQuotaExceeded    = errors.New("gcp quota exceeded for this request")
+)
+
+// RetryPolicy controls which GCP error reasons GetQuotas treats as permanent failures
+// that should short-circuit the rehttp retry loop, instead of being retried until
+// gcp.max-retries is exhausted.
+type RetryPolicy struct {
+	// NonRetryableReasons maps a googleapi error "reason" (e.g. "SERVICE_DISABLED") to
+	// the typed error it should surface as.
+	NonRetryableReasons map[string]error
+}
+
+// DefaultRetryPolicy mirrors the non-retryable reasons the Terraform Google provider's
+// isNotFilestoreQuotaError-style predicates treat as permanent: the API isn't enabled,
+// the caller lacks permission, or the project has no quota left for this call.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		NonRetryableReasons: map[string]error{
+			"SERVICE_DISABLED":                ErrAPIDisabled,
+			"ACCESS_TOKEN_SCOPE_INSUFFICIENT": ErrPermissionDenied,
+			"IAM_PERMISSION_DENIED":           ErrPermissionDenied,
+			"PERMISSION_DENIED":               ErrPermissionDenied,
+			"RATE_LIMIT_EXCEEDED":             ErrQuotaExceeded,
+			"USER_RATE_LIMIT_EXCEEDED":        ErrQuotaExceeded,
+			"QUOTA_EXCEEDED":                  ErrQuotaExceeded,
+		},
+	}
+}
+
+// WithExtraReasons returns a copy of p with additional reason strings registered as
+// non-retryable, wrapped as ErrPermissionDenied since operators only add reasons they've
+// already confirmed won't resolve on retry. Used for --retry-reasons.
+func (p RetryPolicy) WithExtraReasons(reasons []string) RetryPolicy {
+	if len(reasons) == 0 {
+		return p
+	}
+
+	merged := make(map[string]error, len(p.NonRetryableReasons)+len(reasons))
+	for reason, err := range p.NonRetryableReasons {
+		merged[reason] = err
+	}
+	for _, reason := range reasons {
+		if _, ok := merged[reason]; !ok {
+			merged[reason] = ErrPermissionDenied
+		}
+	}
+
+	return RetryPolicy{NonRetryableReasons: merged}
+}
+
+// classifyReason maps a single googleapi error "reason" to the typed error it should
+// surface as, or nil if the policy doesn't treat it as non-retryable.
+func (p RetryPolicy) classifyReason(reason string) error {
+	return p.NonRetryableReasons[reason]
+}
+
+// classify inspects a googleapi error's reason codes and returns the typed error it
+// maps to, or nil if none of the policy's non-retryable reasons match.
+func (p RetryPolicy) classify(err error) error {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+
+	for _, item := range apiErr.Errors {
+		if classified := p.classifyReason(item.Reason); classified != nil {
+			return classified
+		}
+	}
+
+	return nil
+}
+
+// reasonsFromBody extracts the googleapi-style error reason codes from a raw JSON
+// response body, without otherwise interpreting it.
+func reasonsFromBody(body []byte) []string {
+	var payload struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+
+	if json.Unmarshal(body, &payload) != nil {
+		return nil
+	}
+
+	reasons := make([]string, 0, len(payload.Error.Errors))
+	for _, item := range payload.Error.Errors {
+		reasons = append(reasons, item.Reason)
+	}
+
+	return reasons
+}
+
+// retryFn builds a rehttp.RetryFn that fails fast on the policy's non-retryable
+// reasons and otherwise falls back to retrying the configured HTTP statuses.
+//
+// It must not consume attempt.Response.Body without restoring it: rehttp hands the
+// exact same *http.Response back to the caller whenever it decides not to retry, and
+// the compute client's own Do() needs an intact body to decode the real error from.
+func (p RetryPolicy) retryFn(statuses []int) rehttp.RetryFn {
+	retryOnStatus := rehttp.RetryStatuses(statuses...)
+
+	return func(attempt rehttp.Attempt) bool {
+		if attempt.Response != nil && attempt.Response.Body != nil {
+			body, err := io.ReadAll(attempt.Response.Body)
+			attempt.Response.Body.Close()
+			attempt.Response.Body = io.NopCloser(bytes.NewReader(body))
+
+			if err == nil {
+				for _, reason := range reasonsFromBody(body) {
+					if p.classifyReason(reason) != nil {
+						return false
+					}
+				}
+			}
+		}
+
+		return retryOnStatus(attempt)
+	}
+}
+
+// classifyAPIError returns a short label for the Prometheus api-errors counter so
+// "won't ever work" failures are visible separately from transient ones.
+func classifyAPIError(err error) string {
+	switch {
+	case errors.Is(err, ErrAPIDisabled):
+		return "api_disabled"
+	case errors.Is(err, ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota_exceeded"
+	default:
+		return "unknown"
+	}
+}
+
+// newRetryingClient builds an HTTP client authenticated for scopes whose transport
+// fails fast on policy's non-retryable reasons and otherwise retries gcp.retry-statuses
+// with jittered backoff. GetQuotas uses it for the compute API.
+// Every QuotaSource that calls GCP directly should go through this so --gcp.max-retries
+// and --retry-reasons apply uniformly.
+func newRetryingClient(ctx context.Context, policy RetryPolicy, scopes ...string) (*http.Client, error) {
+	client, err := google.DefaultClient(ctx, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Google client: %v", err)
+	}
+
+	client.Timeout = *gcpHttpTimeout
+	client.Transport = rehttp.NewTransport(
+		client.Transport, // need to wrap DefaultClient transport
+		rehttp.RetryAll(
+			rehttp.RetryMaxRetries(*gcpMaxRetries),
+			policy.retryFn(*gcpRetryStatuses)), // fail fast on non-retryable reasons, else retry the configured statuses
+		rehttp.ExpJitterDelay(*gcpBackoffJitterBase, *gcpMaxBackoffDuration), // Set timeout to <10s as that is prom default timeout
+	)
+
+	return client, nil
+}
+
+// wrapClassifiedError classifies a terminal GetQuotas error against policy so a
+// non-retryable failure comes back as a typed error instead of a bare googleapi error.
+// The retry transport only decides whether to retry; typed errors are produced here.
+func wrapClassifiedError(policy RetryPolicy, projectId string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if classified := policy.classify(err); classified != nil {
+		return fmt.Errorf("project %s: %w", projectId, classified)
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestRetryPolicyClassify(t *testing.T) {
+	policy := DefaultRetryPolicy()
+
+	tests := []struct {
+		name   string
+		err    error
+		wantIs error
+	}{
+		{
+			name:   "service disabled",
+			err:    &googleapi.Error{Errors: []googleapi.ErrorItem{{Reason: "SERVICE_DISABLED"}}},
+			wantIs: ErrAPIDisabled,
+		},
+		{
+			name:   "quota exceeded",
+			err:    &googleapi.Error{Errors: []googleapi.ErrorItem{{Reason: "QUOTA_EXCEEDED"}}},
+			wantIs: ErrQuotaExceeded,
+		},
+		{
+			name:   "unmapped reason",
+			err:    &googleapi.Error{Errors: []googleapi.ErrorItem{{Reason: "BACKEND_ERROR"}}},
+			wantIs: nil,
+		},
+		{
+			name:   "not a googleapi error",
+			err:    errors.New("boom"),
+			wantIs: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.classify(tt.err)
+			if tt.wantIs == nil {
+				if got != nil {
+					t.Errorf("classify(%v) = %v, want nil", tt.err, got)
+				}
+				return
+			}
+			if !errors.Is(got, tt.wantIs) {
+				t.Errorf("classify(%v) = %v, want %v", tt.err, got, tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyWithExtraReasons(t *testing.T) {
+	policy := DefaultRetryPolicy().WithExtraReasons([]string{"CUSTOM_REASON"})
+
+	err := policy.classify(&googleapi.Error{Errors: []googleapi.ErrorItem{{Reason: "CUSTOM_REASON"}}})
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected extra reason to classify as ErrPermissionDenied, got %v", err)
+	}
+
+	// Built-in mappings must survive merging extra reasons.
+	err = policy.classify(&googleapi.Error{Errors: []googleapi.ErrorItem{{Reason: "SERVICE_DISABLED"}}})
+	if !errors.Is(err, ErrAPIDisabled) {
+		t.Errorf("expected built-in reason to still classify as ErrAPIDisabled, got %v", err)
+	}
+}
+
+func TestReasonsFromBody(t *testing.T) {
+	body := []byte(`{"error":{"errors":[{"reason":"QUOTA_EXCEEDED"},{"reason":"RATE_LIMIT_EXCEEDED"}]}}`)
+
+	got := reasonsFromBody(body)
+	want := []string{"QUOTA_EXCEEDED", "RATE_LIMIT_EXCEEDED"}
+
+	if len(got) != len(want) {
+		t.Fatalf("reasonsFromBody() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("reasonsFromBody()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReasonsFromBodyInvalidJSON(t *testing.T) {
+	if got := reasonsFromBody([]byte("not json")); got != nil {
+		t.Errorf("reasonsFromBody(invalid) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/serviceusage/v1beta1"
+)
+
+// serviceUsageQuotaSource compares the effective limits of every enabled service's
+// consumer quota metrics, covering the APIs (pub/sub, BigQuery, IAM, Run, ...) that
+// compute/v1 doesn't surface quotas for.
+type serviceUsageQuotaSource struct{}
+
+func (serviceUsageQuotaSource) Name() string { return "serviceusage" }
+
+func (serviceUsageQuotaSource) Compare(ctx context.Context, pair projectPair, policy RetryPolicy) ([]Issue, error) {
+	fromLimits, err := consumerQuotaLimits(ctx, policy, pair.fromProject.ProjectId)
+	if err != nil {
+		return nil, fmt.Errorf("error with project %s: %v", pair.fromProject.ProjectId, err)
+	}
+
+	toLimits, err := consumerQuotaLimits(ctx, policy, pair.toProject.ProjectId)
+	if err != nil {
+		return nil, fmt.Errorf("error with project %s: %v", pair.toProject.ProjectId, err)
+	}
+
+	var issues []Issue
+
+	for metric, fromLimit := range fromLimits {
+		toLimit, ok := toLimits[metric]
+		if !ok {
+			log.Printf("[%s]: Service Usage metric %s does not exist", pair.fromProject.ProjectId, metric)
+			continue
+		}
+
+		if toLimit != fromLimit {
+			issues = append(issues, Issue{
+				fromProjectId: pair.fromProject.DisplayName,
+				toProjectId:   pair.toProject.DisplayName,
+				service:       serviceFromMetric(metric),
+				source:        "serviceusage",
+				metric:        metric,
+				fromLimit:     fromLimit,
+				toLimit:       toLimit,
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// consumerQuotaLimits returns the effective limit of every enabled service's default
+// (unscoped) consumer quota bucket for projectId, keyed by metric name. The per-service
+// ConsumerQuotaMetrics.List loop below makes one call per enabled service, so it goes through policy's
+// retry/backoff the same way GetQuotas does.
+func consumerQuotaLimits(ctx context.Context, policy RetryPolicy, projectId string) (map[string]float64, error) {
+	client, err := newRetryingClient(ctx, policy, serviceusage.CloudPlatformReadOnlyScope)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := serviceusage.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := service.Services.List(fmt.Sprintf("projects/%s", projectId)).Filter("state:ENABLED").Do()
+	if err != nil {
+		return nil, err
+	}
+
+	limits := map[string]float64{}
+
+	for _, svc := range enabled.Services {
+		detail, err := service.Services.ConsumerQuotaMetrics.List(svc.Name).Do()
+		if err != nil {
+			log.Printf("Failure when querying consumer quota metrics for %s: %v", svc.Name, err)
+			continue
+		}
+
+		for _, metric := range detail.Metrics {
+			for _, limit := range metric.ConsumerQuotaLimits {
+				for _, bucket := range limit.QuotaBuckets {
+					if len(bucket.Dimensions) > 0 {
+						continue // only compare the default, unscoped bucket
+					}
+					limits[metric.Metric] = float64(bucket.EffectiveLimit)
+				}
+			}
+		}
+	}
+
+	return limits, nil
+}
+
+// serviceFromMetric extracts the service name from a "<service>/<metric>" style
+// Service Usage metric name, e.g. "pubsub.googleapis.com/topics" -> "pubsub.googleapis.com".
+func serviceFromMetric(metric string) string {
+	if i := strings.Index(metric, "/"); i != -1 {
+		return metric[:i]
+	}
+	return metric
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestServiceFromMetric(t *testing.T) {
+	tests := []struct {
+		metric string
+		want   string
+	}{
+		{"pubsub.googleapis.com/topics", "pubsub.googleapis.com"},
+		{"bigquery.googleapis.com/quota/query/usage", "bigquery.googleapis.com"},
+		{"no-slash-metric", "no-slash-metric"},
+	}
+
+	for _, tt := range tests {
+		if got := serviceFromMetric(tt.metric); got != tt.want {
+			t.Errorf("serviceFromMetric(%q) = %q, want %q", tt.metric, got, tt.want)
+		}
+	}
+}
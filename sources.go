@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// QuotaSource fetches and diffs quota issues for a from/to project pair from a single
+// GCP API surface (compute, Service Usage, Cloud Monitoring, ...).
+type QuotaSource interface {
+	// Name identifies the source for --sources selection and the Issue.source field.
+	Name() string
+	// Compare fetches quotas for both sides of pair and returns any issues found.
+	Compare(ctx context.Context, pair projectPair, policy RetryPolicy) ([]Issue, error)
+}
+
+var quotaSources = map[string]QuotaSource{
+	"compute":      computeQuotaSource{},
+	"serviceusage": serviceUsageQuotaSource{},
+	"monitoring":   monitoringQuotaSource{},
+}
+
+// resolveQuotaSources turns the comma-separated --sources flag into the QuotaSource
+// implementations to run.
+func resolveQuotaSources(flag string) ([]QuotaSource, error) {
+	names := strings.Split(flag, ",")
+
+	resolved := make([]QuotaSource, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+
+		source, ok := quotaSources[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown quota source %q", name)
+		}
+		resolved = append(resolved, source)
+	}
+
+	return resolved, nil
+}
+
+// computeQuotaSource is the original compute/v1 project and region quota comparison,
+// adapted to the QuotaSource interface.
+type computeQuotaSource struct{}
+
+func (computeQuotaSource) Name() string { return "compute" }
+
+func (computeQuotaSource) Compare(ctx context.Context, pair projectPair, policy RetryPolicy) ([]Issue, error) {
+	err, fromQuotas := cachedGetQuotas(pair.fromProject.ProjectId, policy)
+	if err != nil {
+		return nil, fmt.Errorf("error with project %s: %w", pair.fromProject.ProjectId, err)
+	}
+	recordQuotas(pair.fromProject.ProjectId, fromQuotas)
+
+	err, toQuotas := cachedGetQuotas(pair.toProject.ProjectId, policy)
+	if err != nil {
+		return nil, fmt.Errorf("error with project %s: %w", pair.toProject.ProjectId, err)
+	}
+	recordQuotas(pair.toProject.ProjectId, toQuotas)
+
+	return diffQuotas(pair, fromQuotas, toQuotas), nil
+}